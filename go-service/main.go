@@ -1,11 +1,17 @@
 package main
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math"
+	"math/bits"
+	"net"
 	"net/http"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,86 +23,319 @@ type Event struct {
 }
 
 type Stats struct {
-	TotalRequests int64   `json:"totalRequests"`
-	UniqueUsers   int64   `json:"uniqueUsers"`
-	Sum           float64 `json:"sum"`
-	Avg           float64 `json:"avg"`
+	TotalRequests     int64   `json:"totalRequests"`
+	UniqueUsers       int64   `json:"uniqueUsers"`
+	Sum               float64 `json:"sum"`
+	Avg               float64 `json:"avg"`
+	UniqueUsersMode   string  `json:"uniqueUsersMode"`
+	UniqueUsersStdErr float64 `json:"uniqueUsersStdErr,omitempty"`
 }
 
-type EventStore struct {
+// Unique-user counting modes accepted by the -unique flag.
+const (
+	uniqueModeExact = "exact"
+	uniqueModeHLL   = "hll"
+)
+
+// Event ingestion protocols accepted by the -protocol flag. A gRPC backend
+// was requested alongside raw-TCP, but EventService.Ingest needs the
+// google.golang.org/grpc module and generated stubs; this repo has no
+// go.mod/vendoring to pull them in, so grpc is left unimplemented rather
+// than wired up to a stub that would silently fall back to another
+// protocol. Revisit once the module can vendor grpc.
+const (
+	protocolHTTP = "http"
+	protocolTCP  = "tcp"
+)
+
+// eventShard holds one slice of the aggregate state. Sharding by UserID means
+// the same user always lands on the same shard (so uniqueness tracking stays
+// correct) while unrelated users no longer contend on the same CAS loop or
+// map lock.
+type eventShard struct {
 	totalRequests int64
-	sum           uint64
-	users         sync.Map
-	userCount     int64
+
+	// sum/c hold a Neumaier-compensated running total (sum plus the
+	// accumulated correction term) so per-shard precision doesn't drift
+	// across a million mixed-magnitude additions. Guarded by sumMu rather
+	// than CAS since the two fields must update together.
+	sumMu sync.Mutex
+	sum   float64
+	c     float64
+
+	mu    sync.RWMutex
+	users map[string]struct{} // exact mode only
+
+	hll *hyperLogLog // hll mode only
 }
 
-func NewEventStore() *EventStore {
-	return &EventStore{
-		users: sync.Map{},
+// addNeumaier folds x into the compensated (sum, c) pair. See Neumaier's
+// improvement on Kahan summation: https://en.wikipedia.org/wiki/Kahan_summation_algorithm#Further_enhancements
+func addNeumaier(sum, c *float64, x float64) {
+	t := *sum + x
+	if math.Abs(*sum) >= math.Abs(x) {
+		*c += (*sum - t) + x
+	} else {
+		*c += (x - t) + *sum
 	}
+	*sum = t
 }
 
-func (es *EventStore) AddEvent(event Event) {
-	atomic.AddInt64(&es.totalRequests, 1)
+// shardSum returns this shard's compensated total (sum + correction term).
+func (s *eventShard) shardSum() float64 {
+	s.sumMu.Lock()
+	defer s.sumMu.Unlock()
+	return s.sum + s.c
+}
 
-	sumBits := atomic.LoadUint64(&es.sum)
-	for {
-		newSum := float64FromBits(sumBits) + event.Value
-		newSumBits := float64ToBits(newSum)
-		if atomic.CompareAndSwapUint64(&es.sum, sumBits, newSumBits) {
-			break
+// EventStore aggregates events across a fixed number of shards, one per
+// GOMAXPROCS, to keep the per-shard sum lock and user-set lock under
+// contention proportional to core count rather than to total request rate.
+type EventStore struct {
+	shards     []*eventShard
+	uniqueMode string
+}
+
+func NewEventStore(uniqueMode string) *EventStore {
+	numShards := runtime.GOMAXPROCS(0)
+	if numShards < 1 {
+		numShards = 1
+	}
+
+	shards := make([]*eventShard, numShards)
+	for i := range shards {
+		shard := &eventShard{}
+		switch uniqueMode {
+		case uniqueModeHLL:
+			shard.hll = newHyperLogLog()
+		default:
+			shard.users = make(map[string]struct{})
 		}
-		sumBits = atomic.LoadUint64(&es.sum)
+		shards[i] = shard
 	}
 
-	if _, exists := es.users.LoadOrStore(event.UserID, struct{}{}); !exists {
-		atomic.AddInt64(&es.userCount, 1)
+	return &EventStore{shards: shards, uniqueMode: uniqueMode}
+}
+
+func (es *EventStore) shardFor(userID string) *eventShard {
+	return es.shards[fnv1aHash(userID)%uint64(len(es.shards))]
+}
+
+// AddEvent routes every field — counter, sum, and user-set — through the
+// single user-hash shard from shardFor. The original request also asked for
+// a *second*, goroutine/P-local shard for the counter and sum (separate from
+// the user-hash shard used for uniqueness), to cut contention further for
+// producers that hammer one heavy user. That's dropped here: Go doesn't
+// expose the running P index, and the usual trick (hashing the goroutine ID)
+// needs an unsupported runtime hack to get at it, which isn't worth carrying
+// for a benchmark tool. One shard per user-hash bucket is what's implemented.
+func (es *EventStore) AddEvent(event Event) {
+	shard := es.shardFor(event.UserID)
+
+	atomic.AddInt64(&shard.totalRequests, 1)
+
+	shard.sumMu.Lock()
+	addNeumaier(&shard.sum, &shard.c, event.Value)
+	shard.sumMu.Unlock()
+
+	if es.uniqueMode == uniqueModeHLL {
+		shard.hll.add(event.UserID)
+		return
+	}
+
+	shard.mu.RLock()
+	_, exists := shard.users[event.UserID]
+	shard.mu.RUnlock()
+	if exists {
+		return
 	}
+
+	shard.mu.Lock()
+	shard.users[event.UserID] = struct{}{}
+	shard.mu.Unlock()
 }
 
 func (es *EventStore) GetStats() Stats {
-	totalReqs := atomic.LoadInt64(&es.totalRequests)
-	sumValue := float64FromBits(atomic.LoadUint64(&es.sum))
-	uniqueUsers := atomic.LoadInt64(&es.userCount)
+	var totalReqs int64
+	var sumValue float64
+
+	stats := Stats{UniqueUsersMode: es.uniqueMode}
+
+	if es.uniqueMode == uniqueModeHLL {
+		merged := newHyperLogLog()
+		for _, shard := range es.shards {
+			totalReqs += atomic.LoadInt64(&shard.totalRequests)
+			sumValue += shard.shardSum()
+			merged.mergeFrom(shard.hll)
+		}
+		stats.UniqueUsers = int64(merged.estimate())
+		stats.UniqueUsersStdErr = hllStdErr
+	} else {
+		var uniqueUsers int64
+		for _, shard := range es.shards {
+			totalReqs += atomic.LoadInt64(&shard.totalRequests)
+			sumValue += shard.shardSum()
+
+			shard.mu.RLock()
+			uniqueUsers += int64(len(shard.users))
+			shard.mu.RUnlock()
+		}
+		stats.UniqueUsers = uniqueUsers
+	}
 
-	var avg float64
+	stats.TotalRequests = totalReqs
+	stats.Sum = sumValue
 	if totalReqs > 0 {
-		avg = sumValue / float64(totalReqs)
+		stats.Avg = sumValue / float64(totalReqs)
 	}
 
-	return Stats{
-		TotalRequests: totalReqs,
-		UniqueUsers:   uniqueUsers,
-		Sum:           sumValue,
-		Avg:           avg,
+	return stats
+}
+
+// hllPrecision/hllM size the HyperLogLog sketch: 2^14 = 16384 registers, 6
+// bits each is enough to count leading-zero runs in a 64-bit hash without
+// overflow, giving a standard error of ~1.04/sqrt(m) regardless of
+// cardinality.
+const (
+	hllPrecision = 14
+	hllM         = 1 << hllPrecision
+	hllStdErr    = 1.04 / 128 // 1.04/sqrt(hllM), hllM=16384
+)
+
+// hyperLogLog is a sharded-friendly HyperLogLog sketch: register-wise max is
+// the standard HLL merge operation, so combining the per-shard sketches
+// (each fed a disjoint slice of users by shardFor) reconstructs the same
+// estimate a single global sketch would have produced.
+type hyperLogLog struct {
+	mu        sync.Mutex
+	registers [hllM]uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{}
+}
+
+func (h *hyperLogLog) add(userID string) {
+	x := mixHash(fnv1aHash(userID))
+	idx := x >> (64 - hllPrecision)
+	w := x<<hllPrecision | (1 << (hllPrecision - 1)) // keep leading-zero count finite
+	rho := uint8(bits.LeadingZeros64(w)) + 1
+
+	h.mu.Lock()
+	if rho > h.registers[idx] {
+		h.registers[idx] = rho
 	}
+	h.mu.Unlock()
 }
 
-func float64ToBits(f float64) uint64 {
-	return math.Float64bits(f)
+// mergeFrom folds another sketch's registers into this one by taking the
+// per-register max, the standard way to combine two HyperLogLog sketches.
+func (h *hyperLogLog) mergeFrom(other *hyperLogLog) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+
+	for i, r := range other.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+}
+
+// estimate applies the standard HyperLogLog bias-corrected estimator, with
+// the small-range linear-counting correction used when many registers are
+// still empty.
+func (h *hyperLogLog) estimate() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	m := float64(hllM)
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1.0 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	raw := alpha * m * m / sum
+
+	if raw <= 2.5*m && zeros > 0 {
+		return m * math.Log(m/float64(zeros))
+	}
+	return raw
+}
+
+// mixHash spreads fnv1aHash's output across all 64 bits before it's split
+// into a register index and a leading-zero-count tail, so the two uses of
+// the hash (shard selection elsewhere, index/rho here) don't correlate.
+func mixHash(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
 }
 
-func float64FromBits(b uint64) float64 {
-	return math.Float64frombits(b)
+// fnv1aHash is the 64-bit FNV-1a hash, used to pick a user's shard. It's
+// cheap and spreads short UserID strings evenly enough for shard selection.
+func fnv1aHash(s string) uint64 {
+	const (
+		offsetBasis = 14695981039346656037
+		prime       = 1099511628211
+	)
+	h := uint64(offsetBasis)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime
+	}
+	return h
 }
 
 var store *EventStore
 
 func main() {
-	store = NewEventStore()
+	uniqueMode := flag.String("unique", uniqueModeExact, "Unique user counting mode: exact or hll")
+	protocol := flag.String("protocol", protocolHTTP, "Event ingestion protocol: http or tcp")
+	addr := flag.String("addr", ":8080", "HTTP listen address (stats/health are always served here)")
+	tcpAddr := flag.String("tcp-addr", ":8081", "TCP listen address, used when -protocol=tcp")
+	flag.Parse()
+
+	switch *uniqueMode {
+	case uniqueModeExact, uniqueModeHLL:
+	default:
+		log.Fatalf("invalid -unique %q: must be %q or %q", *uniqueMode, uniqueModeExact, uniqueModeHLL)
+	}
+
+	switch *protocol {
+	case protocolHTTP, protocolTCP:
+	default:
+		log.Fatalf("invalid -protocol %q: must be %q or %q", *protocol, protocolHTTP, protocolTCP)
+	}
 
-	http.HandleFunc("/event", handleEvent)
+	store = NewEventStore(*uniqueMode)
+
+	// /stats and /health are always reachable over HTTP regardless of which
+	// protocol ingests events, so the load-tester can query them the same
+	// way no matter what -protocol the server was started with.
+	if *protocol == protocolHTTP {
+		http.HandleFunc("/event", handleEvent)
+	} else {
+		go serveTCP(*tcpAddr)
+	}
 	http.HandleFunc("/stats", handleStats)
 	http.HandleFunc("/health", handleHealth)
 
 	server := &http.Server{
-		Addr:           ":8080",
+		Addr:           *addr,
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	fmt.Println("Server starting on :8080")
+	fmt.Printf("Server starting on %s (protocol=%s)\n", *addr, *protocol)
 	log.Fatal(server.ListenAndServe())
 }
 
@@ -122,6 +361,78 @@ func handleEvent(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// tcpAck is the length-prefixed JSON response frame for -protocol=tcp.
+type tcpAck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// serveTCP accepts raw, length-prefixed JSON event frames: a 4-byte
+// big-endian length prefix followed by a JSON-encoded Event, one connection
+// per client with a per-connection read loop. This exists to let the 1MRC
+// benchmark separate HTTP/JSON framing overhead from actual aggregation
+// cost (see the matching client in loadtest-go).
+func serveTCP(addr string) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("tcp listen on %s: %v", addr, err)
+	}
+	fmt.Printf("TCP ingestion listening on %s\n", addr)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Printf("tcp accept: %v", err)
+			continue
+		}
+		go handleTCPConn(conn)
+	}
+}
+
+func handleTCPConn(conn net.Conn) {
+	defer conn.Close()
+
+	var lenBuf [4]byte
+	for {
+		if _, err := io.ReadFull(conn, lenBuf[:]); err != nil {
+			return
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(lenBuf[:]))
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			return
+		}
+
+		var event Event
+		ack := tcpAck{OK: true}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			ack = tcpAck{OK: false, Error: "invalid JSON"}
+		} else if event.UserID == "" {
+			ack = tcpAck{OK: false, Error: "userId is required"}
+		} else {
+			store.AddEvent(event)
+		}
+
+		if err := writeTCPFrame(conn, ack); err != nil {
+			return
+		}
+	}
+}
+
+func writeTCPFrame(conn net.Conn, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(body)))
+	if _, err := conn.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err = conn.Write(body)
+	return err
+}
+
 
 func handleStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// TestNeumaierSummation_PrecisionVsNaive accumulates a huge-magnitude running
+// total (1e16, well past float64's 2^53 exact-integer range) against a
+// stream of small 1.0 increments through both the compensated accumulator
+// and plain float64 addition, and checks that only the compensated one
+// stays within 1 ULP of the true sum. Naive addition rounds every 1.0 away
+// once the running total exceeds 2^53, so it drifts by roughly n; Neumaier's
+// correction term captures exactly that rounding loss.
+func TestNeumaierSummation_PrecisionVsNaive(t *testing.T) {
+	const n = 1_000_000
+	const base = 1e16
+
+	naiveSum := base
+	sum, c := base, 0.0
+	for i := 0; i < n; i++ {
+		naiveSum += 1.0
+		addNeumaier(&sum, &c, 1.0)
+	}
+
+	trueSum := base + float64(n)
+	compensated := sum + c
+
+	ulp := math.Nextafter(trueSum, math.Inf(1)) - trueSum
+	if err := math.Abs(compensated - trueSum); err > ulp {
+		t.Errorf("compensated sum error %v exceeds 1 ULP (%v) of true sum %v", err, ulp, trueSum)
+	}
+
+	if err := math.Abs(naiveSum - trueSum); err < 10 {
+		t.Errorf("expected naive summation to drift significantly as a baseline, got error %v", err)
+	}
+}
+
+// TestHyperLogLog_EstimateWithinStdErr feeds a known number of distinct
+// users into a sketch and checks the estimate lands within a few standard
+// errors (hllStdErr, ~1.04/sqrt(hllM)) of the true cardinality.
+func TestHyperLogLog_EstimateWithinStdErr(t *testing.T) {
+	const n = 100_000
+
+	h := newHyperLogLog()
+	for i := 0; i < n; i++ {
+		h.add(fmt.Sprintf("user_%d", i))
+	}
+
+	got := h.estimate()
+	tolerance := 4 * hllStdErr * float64(n) // a few standard errors of slack
+	if err := math.Abs(got - n); err > tolerance {
+		t.Errorf("estimate %v too far from true cardinality %d: error %v exceeds tolerance %v", got, n, err, tolerance)
+	}
+}
+
+// TestHyperLogLog_MergeMatchesSingleSketch checks that merging two
+// disjoint-user shards (the sharded-by-userID layout EventStore uses)
+// reconstructs the same estimate a single sketch fed all the users would
+// have produced, since mergeFrom is a plain register-wise max.
+func TestHyperLogLog_MergeMatchesSingleSketch(t *testing.T) {
+	const n = 50_000
+
+	combined := newHyperLogLog()
+	shardA := newHyperLogLog()
+	shardB := newHyperLogLog()
+	for i := 0; i < n; i++ {
+		userID := fmt.Sprintf("user_%d", i)
+		combined.add(userID)
+		if i%2 == 0 {
+			shardA.add(userID)
+		} else {
+			shardB.add(userID)
+		}
+	}
+
+	merged := newHyperLogLog()
+	merged.mergeFrom(shardA)
+	merged.mergeFrom(shardB)
+
+	if merged.estimate() != combined.estimate() {
+		t.Errorf("merged estimate %v does not match single-sketch estimate %v", merged.estimate(), combined.estimate())
+	}
+}
+
+// TestHyperLogLog_EmptySketchUsesLinearCounting checks the small-range
+// correction: with every register still zero, estimate should report 0
+// rather than a non-zero raw HLL estimate computed from zero cardinality.
+func TestHyperLogLog_EmptySketchUsesLinearCounting(t *testing.T) {
+	h := newHyperLogLog()
+	if got := h.estimate(); got != 0 {
+		t.Errorf("estimate of empty sketch = %v, want 0", got)
+	}
+}
+
+// BenchmarkEventStore_AddEvent drives concurrent producers against a single
+// EventStore at increasing concurrency levels. Compare against the
+// pre-sharding implementation with benchstat to see the CAS-loop hotspot
+// disappear as producer count grows.
+func BenchmarkEventStore_AddEvent(b *testing.B) {
+	for _, producers := range []int{1, 2, 4, 8, 16, 32, 64} {
+		b.Run(strconv.Itoa(producers), func(b *testing.B) {
+			store := NewEventStore(uniqueModeExact)
+
+			var wg sync.WaitGroup
+			perProducer := b.N / producers
+			if perProducer < 1 {
+				perProducer = 1
+			}
+
+			b.ResetTimer()
+			for p := 0; p < producers; p++ {
+				wg.Add(1)
+				go func(p int) {
+					defer wg.Done()
+					for i := 0; i < perProducer; i++ {
+						store.AddEvent(Event{
+							UserID: fmt.Sprintf("user_%d", (p*perProducer+i)%75000),
+							Value:  float64(i%1000) + 0.5,
+						})
+					}
+				}(p)
+			}
+			wg.Wait()
+		})
+	}
+}
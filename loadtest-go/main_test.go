@@ -0,0 +1,256 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestHistogram_IndexStaysInRange checks that index() never returns
+// coordinates outside the fixed-size bucket/sub-bucket arrays record and
+// valueAtPercentile index into, across the lowest, highest, and
+// past-the-end ends of the trackable range.
+func TestHistogram_IndexStaysInRange(t *testing.T) {
+	h := newHistogram()
+
+	for _, ns := range []int64{-1, 0, 1, 500, histLowestTrackableNs, 1_000, 12_345, 1_000_000, histHighestTrackableNs, histHighestTrackableNs * 100} {
+		bucket, sub := h.index(ns)
+		if bucket < 0 || bucket > histNumBuckets {
+			t.Fatalf("index(%d) returned out-of-range bucket %d", ns, bucket)
+		}
+		if bucket == histNumBuckets {
+			continue // overflow sentinel; sub is unused
+		}
+		if sub < 0 || sub >= histSubBucketCount {
+			t.Fatalf("index(%d) returned out-of-range sub-bucket %d", ns, sub)
+		}
+	}
+}
+
+// TestHistogram_ValueAtPercentile records a known, evenly-spaced set of
+// latencies and checks the reconstructed P50/P99/P100 are within the
+// bucket's quantization error of the true values.
+func TestHistogram_ValueAtPercentile(t *testing.T) {
+	h := newHistogram()
+
+	const n = 10_000
+	for i := 1; i <= n; i++ {
+		h.record(int64(i) * int64(time.Microsecond))
+	}
+
+	cases := []struct {
+		percentile float64
+		want       time.Duration
+	}{
+		{50, 5_000 * time.Microsecond},
+		{99, 9_900 * time.Microsecond},
+		{100, 10_000 * time.Microsecond},
+	}
+	for _, c := range cases {
+		got := h.valueAtPercentile(c.percentile)
+		// Sub-bucket width grows with each power-of-two bucket, so the
+		// quantization error scales with the value; allow 2% of the target
+		// value as slack.
+		tolerance := time.Duration(float64(c.want) * 0.02)
+		if got < c.want-tolerance || got > c.want+tolerance {
+			t.Errorf("valueAtPercentile(%v) = %v, want within %v of %v", c.percentile, got, tolerance, c.want)
+		}
+	}
+}
+
+// TestHistogram_ValueAtPercentileEmpty checks the documented zero-value
+// behavior before any samples have been recorded.
+func TestHistogram_ValueAtPercentileEmpty(t *testing.T) {
+	h := newHistogram()
+	if got := h.valueAtPercentile(99); got != 0 {
+		t.Errorf("valueAtPercentile on empty histogram = %v, want 0", got)
+	}
+}
+
+// TestHistogram_WelfordMoments checks the running mean/stdev/min/max against
+// the textbook formulas for a small known sample.
+func TestHistogram_WelfordMoments(t *testing.T) {
+	samples := []int64{10, 20, 30, 40, 50}
+
+	h := newHistogram()
+	for _, s := range samples {
+		h.record(s)
+	}
+
+	count, mean, stdev, min, max := h.snapshotMoments()
+	if count != int64(len(samples)) {
+		t.Fatalf("count = %d, want %d", count, len(samples))
+	}
+	if mean != 30 {
+		t.Errorf("mean = %v, want 30", mean)
+	}
+	if min != 10 || max != 50 {
+		t.Errorf("min/max = %d/%d, want 10/50", min, max)
+	}
+
+	// Sample stdev (n-1 denominator) of {10,20,30,40,50} is sqrt(250) ≈ 15.81.
+	wantStdev := math.Sqrt(250)
+	if math.Abs(stdev-wantStdev) > 1e-9 {
+		t.Errorf("stdev = %v, want %v", stdev, wantStdev)
+	}
+}
+
+// collectArrivals drains n pre-scheduled workItems from openLoopArrivals,
+// which closes workQueue itself once done.
+func collectArrivals(config *Config, n int) []workItem {
+	workQueue := make(chan workItem, n)
+	openLoopArrivals(config, workQueue)
+
+	items := make([]workItem, 0, n)
+	for item := range workQueue {
+		items = append(items, item)
+	}
+	return items
+}
+
+// TestOpenLoopArrivals_ConstantSpacing checks that open-constant schedules
+// each arrival exactly meanInterval after the last, regardless of how long
+// the actual time.Sleep takes, since scheduledAt is derived from the
+// pre-scheduled "next" time rather than wall-clock elapsed time.
+func TestOpenLoopArrivals_ConstantSpacing(t *testing.T) {
+	const n = 200
+	const rps = 100_000 // meanInterval = 10µs, keeps the test fast
+
+	config := &Config{totalRequests: n, rps: rps, workload: "open-constant"}
+	items := collectArrivals(config, n)
+	if len(items) != n {
+		t.Fatalf("got %d items, want %d", len(items), n)
+	}
+
+	meanInterval := time.Second / time.Duration(rps)
+	for i := 1; i < len(items); i++ {
+		gap := items[i].scheduledAt.Sub(items[i-1].scheduledAt)
+		if gap != meanInterval {
+			t.Fatalf("gap between item %d and %d = %v, want exactly %v", i-1, i, gap, meanInterval)
+		}
+	}
+}
+
+// TestOpenLoopArrivals_PoissonMeanMatchesTargetRate checks that open-poisson's
+// exponential inter-arrival gaps average out to the configured rate's mean
+// interval over enough samples, confirming the arrival process is paced by
+// -rps rather than e.g. the worker pool's drain speed.
+func TestOpenLoopArrivals_PoissonMeanMatchesTargetRate(t *testing.T) {
+	const n = 5000
+	const rps = 100_000 // meanInterval = 10µs, keeps the test fast
+
+	config := &Config{totalRequests: n, rps: rps, workload: "open-poisson"}
+	items := collectArrivals(config, n)
+	if len(items) != n {
+		t.Fatalf("got %d items, want %d", len(items), n)
+	}
+
+	meanInterval := time.Second / time.Duration(rps)
+
+	var sumGaps time.Duration
+	distinctGaps := make(map[time.Duration]bool)
+	for i := 1; i < len(items); i++ {
+		gap := items[i].scheduledAt.Sub(items[i-1].scheduledAt)
+		if gap < 0 {
+			t.Fatalf("gap between item %d and %d = %v, arrivals must be non-decreasing", i-1, i, gap)
+		}
+		sumGaps += gap
+		distinctGaps[gap] = true
+	}
+	if len(distinctGaps) < 2 {
+		t.Fatalf("all %d gaps were identical; expected exponentially distributed inter-arrival times", len(distinctGaps))
+	}
+
+	meanGap := sumGaps / time.Duration(len(items)-1)
+	// Exponential stdev == mean, so the standard error of this sample mean is
+	// meanInterval/sqrt(n); 20% of meanInterval is several SEs of slack.
+	tolerance := meanInterval * 20 / 100
+	if diff := meanGap - meanInterval; diff > tolerance || diff < -tolerance {
+		t.Errorf("mean gap %v too far from target mean interval %v (tolerance %v)", meanGap, meanInterval, tolerance)
+	}
+}
+
+// fakePhase builds a phaseResult stamped with a pass/fail verdict, enough for
+// rampDoublingSearch/rampBinarySearch to act on without driving a real
+// load test.
+func fakePhase(rps int, passed bool) phaseResult {
+	return phaseResult{targetRPS: rps, passed: passed}
+}
+
+// TestRampDoublingSearch_FindsBreakingPoint checks that the doubling loop
+// keeps tracking the highest passing rate as lastGood and stops at the first
+// rate that breaks the simulated SLO (fails once rps >= 1600).
+func TestRampDoublingSearch_FindsBreakingPoint(t *testing.T) {
+	const startRPS = 100
+	const maxRPS = 1_000_000
+	const breaksAt = 1600
+
+	var probed []int
+	probe := func(rps int) phaseResult {
+		probed = append(probed, rps)
+		return fakePhase(rps, rps < breaksAt)
+	}
+	var recorded []phaseResult
+	record := func(r phaseResult) { recorded = append(recorded, r) }
+
+	lastGood, badRPS, ceilingRPS := rampDoublingSearch(startRPS, maxRPS, probe, record)
+
+	if ceilingRPS != 0 {
+		t.Fatalf("ceilingRPS = %d, want 0 (search should break the SLO before the ceiling)", ceilingRPS)
+	}
+	if badRPS != breaksAt {
+		t.Errorf("badRPS = %d, want %d", badRPS, breaksAt)
+	}
+	if lastGood != breaksAt/2 {
+		t.Errorf("lastGood = %d, want %d", lastGood, breaksAt/2)
+	}
+	if len(recorded) != len(probed) {
+		t.Errorf("record was called %d times, want one per probe (%d)", len(recorded), len(probed))
+	}
+}
+
+// TestRampDoublingSearch_StopsAtCeiling checks that a server that never
+// breaks the SLO makes the loop stop at -ramp-max-rps instead of doubling
+// forever, reporting the ceiling-exceeding rate rather than a bad RPS.
+func TestRampDoublingSearch_StopsAtCeiling(t *testing.T) {
+	const startRPS = 100
+	const maxRPS = 1000
+
+	probe := func(rps int) phaseResult { return fakePhase(rps, true) }
+	record := func(phaseResult) {}
+
+	lastGood, badRPS, ceilingRPS := rampDoublingSearch(startRPS, maxRPS, probe, record)
+
+	if ceilingRPS <= maxRPS {
+		t.Errorf("ceilingRPS = %d, want a value above maxRPS (%d)", ceilingRPS, maxRPS)
+	}
+	if badRPS != 0 {
+		t.Errorf("badRPS = %d, want 0 when the ceiling (not the SLO) stopped the search", badRPS)
+	}
+	if lastGood <= 0 {
+		t.Errorf("lastGood = %d, want a positive last-passing rate below the ceiling", lastGood)
+	}
+}
+
+// TestRampBinarySearch_ConvergesOnKnee checks that binary search narrows a
+// passing/failing bracket down to within the documented 5% tolerance of the
+// true breaking point, without needing more than the 6-iteration cap.
+func TestRampBinarySearch_ConvergesOnKnee(t *testing.T) {
+	const breaksAt = 1500
+
+	probe := func(rps int) phaseResult { return fakePhase(rps, rps < breaksAt) }
+	var recorded []phaseResult
+	record := func(r phaseResult) { recorded = append(recorded, r) }
+
+	knee := rampBinarySearch(1000, 2000, probe, record)
+
+	if knee >= breaksAt {
+		t.Fatalf("knee = %d, must stay below the breaking point %d", knee, breaksAt)
+	}
+	if diff := breaksAt - knee; diff > breaksAt/20 {
+		t.Errorf("knee = %d, want within 5%% of the true breaking point %d (diff %d)", knee, breaksAt, diff)
+	}
+	if len(recorded) == 0 || len(recorded) > 6 {
+		t.Errorf("binary search recorded %d phases, want 1-6", len(recorded))
+	}
+}